@@ -0,0 +1,85 @@
+// Package model defines the data structures used to describe a DJDNS
+// page: the branches it contains, the records each branch resolves to,
+// and the targets it may delegate to.
+package model
+
+import "strings"
+
+// DefaultTTL is used for any record that neither sets its own TTL nor
+// inherits one from its Branch.
+const DefaultTTL uint32 = 3600
+
+// Record is a single resolved DNS record living inside a Branch. Rdata
+// is intentionally untyped since its shape depends on Rtype: a string
+// for A/AAAA/CNAME/NS records, a []string for TXT, and MXRdata/SRVRdata
+// for the respective richer types. TTL is left zero in hand-authored
+// data and filled in by Normalize.
+type Record struct {
+	DomainName string
+	Rtype      string
+	Rdata      interface{}
+	TTL        uint32
+}
+
+// MXRdata is the Rdata shape expected of an "MX" Record.
+type MXRdata struct {
+	Preference uint16
+	Mx         string
+}
+
+// SRVRdata is the Rdata shape expected of an "SRV" Record.
+type SRVRdata struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+// Branch matches a prefix of a queried domain name (Selector, optionally
+// ending in "*" to document that it matches more than itself) and either
+// resolves directly to Records or delegates to another page via Targets.
+// TTL, if set, is inherited by any Record in the branch that doesn't
+// specify its own.
+type Branch struct {
+	Selector string
+	Records  []Record
+	Targets  []string
+	TTL      uint32
+}
+
+// Data is the resolvable content of a page: the list of branches tried,
+// in order, against an incoming query.
+type Data struct {
+	Branches []Branch
+}
+
+// Page wraps Data as retrieved from a PageGetter.
+type Page struct {
+	Data Data
+}
+
+// Normalize fills in defaults left implicit by hand-authored page data:
+// records default to an "A" Rtype, domain names are made fully
+// qualified (trailing dot) the way miekg/dns expects, and TTLs fall back
+// to the owning branch's default, then DefaultTTL.
+func (d *Data) Normalize() {
+	for i := range d.Branches {
+		branch := &d.Branches[i]
+		for j := range branch.Records {
+			record := &branch.Records[j]
+			if record.Rtype == "" {
+				record.Rtype = "A"
+			}
+			if !strings.HasSuffix(record.DomainName, ".") {
+				record.DomainName += "."
+			}
+			if record.TTL == 0 {
+				if branch.TTL != 0 {
+					record.TTL = branch.TTL
+				} else {
+					record.TTL = DefaultTTL
+				}
+			}
+		}
+	}
+}
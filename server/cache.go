@@ -0,0 +1,123 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultCacheSize is the number of distinct (qname, qtype, qclass)
+// resolutions a DjdnsServer caches at once.
+const DefaultCacheSize = 1000
+
+// DefaultNegativeTTL is how long an empty answer set (no branch matched
+// and no upstream answered for it, or no records of the queried type) is
+// cached for.
+const DefaultNegativeTTL = 30 * time.Second
+
+// cacheKey identifies a resolved answer set.
+type cacheKey struct {
+	qname  string
+	qtype  uint16
+	qclass uint16
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	rrs      []dns.RR
+	storedAt time.Time
+	expiry   time.Time
+}
+
+// responseCache is a bounded LRU of resolved answer sets, safe for
+// concurrent use across Handle calls.
+type responseCache struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[cacheKey]*list.Element
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		ll:    list.New(),
+		items: make(map[cacheKey]*list.Element),
+	}
+}
+
+// get returns a fresh copy of the RRs cached under key, with each RR's
+// TTL reduced by however long it has sat in the cache. ok is false on a
+// miss or on an entry that has outlived its TTL.
+func (c *responseCache) get(key cacheKey) (rrs []dns.RR, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+
+	now := time.Now()
+	if now.After(entry.expiry) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+
+	elapsed := uint32(now.Sub(entry.storedAt) / time.Second)
+	rrs = make([]dns.RR, len(entry.rrs))
+	for i, rr := range entry.rrs {
+		rr = dns.Copy(rr)
+		hdr := rr.Header()
+		if elapsed >= hdr.Ttl {
+			hdr.Ttl = 0
+		} else {
+			hdr.Ttl -= elapsed
+		}
+		rrs[i] = rr
+	}
+	return rrs, true
+}
+
+// put stores rrs under key, due to expire after the lowest TTL among
+// rrs (or negTTL when rrs is empty), and evicts the least recently used
+// entry once the cache holds more than capacity items. A non-positive
+// capacity disables caching entirely.
+func (c *responseCache) put(key cacheKey, rrs []dns.RR, negTTL time.Duration, capacity int) {
+	if capacity <= 0 {
+		return
+	}
+
+	ttl := negTTL
+	for i, rr := range rrs {
+		rrTTL := time.Duration(rr.Header().Ttl) * time.Second
+		if i == 0 || rrTTL < ttl {
+			ttl = rrTTL
+		}
+	}
+
+	now := time.Now()
+	entry := &cacheEntry{key: key, rrs: rrs, storedAt: now, expiry: now.Add(ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, found := c.items[key]; found {
+		el.Value = entry
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[key] = c.ll.PushFront(entry)
+	}
+
+	for c.ll.Len() > capacity {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/miekg/dns"
+)
+
+// dohContentType is the media type required of DNS-over-HTTPS request
+// and response bodies (RFC 8484).
+const dohContentType = "application/dns-message"
+
+// RunHTTPS starts a DNS-over-HTTPS listener (RFC 8484) at /dns-query,
+// resolving queries through the same Handle as Run, and blocks until it
+// stops (typically via Close).
+func (s *DjdnsServer) RunHTTPS(addr, certFile, keyFile string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dns-query", s.serveDoH)
+	s.httpServer = &http.Server{Addr: addr, Handler: mux}
+
+	s.wg.Add(1)
+	defer s.wg.Done()
+	err := s.httpServer.ListenAndServeTLS(certFile, keyFile)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// serveDoH answers a single DoH request: a base64url-encoded query in
+// the "dns" parameter of a GET, or a raw "application/dns-message" body
+// on a POST. The wire-format query is dispatched through Handle exactly
+// as the UDP/TCP front-ends do.
+func (s *DjdnsServer) serveDoH(w http.ResponseWriter, r *http.Request) {
+	var packed []byte
+	var err error
+
+	switch r.Method {
+	case http.MethodGet:
+		packed, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != dohContentType {
+			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+			return
+		}
+		packed, err = ioutil.ReadAll(r.Body)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Malformed query", http.StatusBadRequest)
+		return
+	}
+
+	query := new(dns.Msg)
+	if err := query.Unpack(packed); err != nil {
+		http.Error(w, "Malformed query", http.StatusBadRequest)
+		return
+	}
+
+	response, err := s.Handle(query)
+	if err == ErrUnknownRtype {
+		s.Logger.Println(err)
+	}
+
+	out, err := response.Pack()
+	if err != nil {
+		http.Error(w, "Failed to pack response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", dohContentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", minTTL(response.Answer)))
+	w.Write(out)
+}
+
+// minTTL returns the lowest TTL among rrs, or 0 if rrs is empty.
+func minTTL(rrs []dns.RR) uint32 {
+	if len(rrs) == 0 {
+		return 0
+	}
+	min := rrs[0].Header().Ttl
+	for _, rr := range rrs[1:] {
+		if ttl := rr.Header().Ttl; ttl < min {
+			min = ttl
+		}
+	}
+	return min
+}
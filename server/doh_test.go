@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RTForDoH resolves queries over DNS-over-HTTPS POSTs, letting the
+// shared resolve_tests table exercise RunHTTPS the same way
+// RTForNetwork exercises the plain UDP/TCP listeners.
+type RTForDoH struct {
+	Client  *http.Client
+	BaseURL string
+}
+
+func (tester RTForDoH) GetResponse(query *dns.Msg) (*dns.Msg, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tester.BaseURL, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+
+	resp, err := tester.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response := new(dns.Msg)
+	if err := response.Unpack(body); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+func (tester RTForDoH) WasFailure(msg *dns.Msg, err error) bool {
+	return err != nil || msg.Rcode != dns.RcodeSuccess
+}
+
+func Test_DjdnsServer_RunHTTPS(t *testing.T) {
+	certFile, keyFile := generateTestCert(t)
+
+	s, _ := setupTestData(new(bytes.Buffer))
+	host, port := "127.0.0.1", 8443
+	addr := fmt.Sprintf("%s:%d", host, port)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.RunHTTPS(addr, certFile, keyFile)
+	}()
+	<-time.After(50 * time.Millisecond)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	tester := RTForDoH{client, fmt.Sprintf("https://%s/dns-query", addr)}
+	for _, test := range resolve_tests {
+		testResolution(t, tester, test)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_DjdnsServer_RunHTTPS_GET(t *testing.T) {
+	certFile, keyFile := generateTestCert(t)
+
+	s, _ := setupTestData(new(bytes.Buffer))
+	host, port := "127.0.0.1", 8444
+	addr := fmt.Sprintf("%s:%d", host, port)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.RunHTTPS(addr, certFile, keyFile)
+	}()
+	<-time.After(50 * time.Millisecond)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	query := new(dns.Msg)
+	query.SetQuestion("abcdef.", dns.TypeA)
+	packed, err := query.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(packed)
+
+	resp, err := client.Get(fmt.Sprintf("https://%s/dns-query?dns=%s", addr, encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != dohContentType {
+		t.Fatalf("Expected Content-Type %q, got %q", dohContentType, ct)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := new(dns.Msg)
+	if err := response.Unpack(body); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Answer) != 2 {
+		t.Fatalf("Expected 2 answers, got %d", len(response.Answer))
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatal(err)
+	}
+}
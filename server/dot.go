@@ -0,0 +1,28 @@
+package server
+
+import (
+	"crypto/tls"
+
+	"github.com/miekg/dns"
+)
+
+// RunTLS starts a DNS-over-TLS listener (RFC 7858, conventionally port
+// 853), sharing the same handler as Run, and blocks until it stops
+// (typically via Close).
+func (s *DjdnsServer) RunTLS(addr, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	s.tlsServer = &dns.Server{
+		Addr:      addr,
+		Net:       "tcp-tls",
+		Handler:   s.handler(),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+
+	s.wg.Add(1)
+	defer s.wg.Done()
+	return s.tlsServer.ListenAndServe()
+}
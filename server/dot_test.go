@@ -0,0 +1,54 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RTForDoT resolves queries over DNS-over-TLS, letting the shared
+// resolve_tests table exercise RunTLS the same way RTForNetwork
+// exercises the plain UDP/TCP listeners.
+type RTForDoT struct {
+	Client *dns.Client
+	Addr   string
+}
+
+func (tester RTForDoT) GetResponse(query *dns.Msg) (*dns.Msg, error) {
+	response, _, err := tester.Client.Exchange(query, tester.Addr)
+	return response, err
+}
+func (tester RTForDoT) WasFailure(msg *dns.Msg, err error) bool {
+	return err != nil || msg.Rcode != dns.RcodeSuccess
+}
+
+func Test_DjdnsServer_RunTLS(t *testing.T) {
+	certFile, keyFile := generateTestCert(t)
+
+	s, _ := setupTestData(new(bytes.Buffer))
+	host, port := "127.0.0.1", 8853
+	addr := fmt.Sprintf("%s:%d", host, port)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.RunTLS(addr, certFile, keyFile)
+	}()
+	<-time.After(50 * time.Millisecond)
+
+	client := &dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	tester := RTForDoT{client, addr}
+	for _, test := range resolve_tests {
+		testResolution(t, tester, test)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+// assertError checks err against an expected error string; an empty
+// expected string means no error should have occurred.
+func assertError(t *testing.T, expected string, err error) {
+	if expected == "" {
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("Expected error %q, got nil", expected)
+	}
+	if err.Error() != expected {
+		t.Fatalf("Expected error %q, got %q", expected, err.Error())
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent Write/String, for
+// tests that assert on a DjdnsServer's logged output after it has been
+// served from a request-handling goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
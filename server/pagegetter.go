@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/DJDNS/djdns/model"
+)
+
+// PageGetter resolves a page addressed by a DJDNS target URI, such as
+// "root://" or "gun://somepubkey". Implementations are free to interpret
+// target however their scheme requires.
+type PageGetter interface {
+	GetPage(target string) (model.Page, error)
+}
+
+// AliasPageGetter substitutes well-known names (like "<ROOT>") for their
+// configured target URI before handing the request down the chain. This
+// is how a server's single entry point ("<ROOT>") is wired to whichever
+// scheme actually hosts the root page.
+type AliasPageGetter struct {
+	Aliases map[string]string
+	Next    PageGetter
+}
+
+func (a *AliasPageGetter) GetPage(target string) (model.Page, error) {
+	if alias, ok := a.Aliases[target]; ok {
+		target = alias
+	}
+	return a.Next.GetPage(target)
+}
+
+// SchemePageGetter dispatches a target URI to the PageGetter registered
+// for its URI scheme (the part before "://").
+type SchemePageGetter struct {
+	Children map[string]PageGetter
+}
+
+func (s *SchemePageGetter) GetPage(target string) (model.Page, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return model.Page{}, err
+	}
+	child, ok := s.Children[u.Scheme]
+	if !ok {
+		return model.Page{}, fmt.Errorf("No page getter registered for scheme %q", u.Scheme)
+	}
+	return child.GetPage(target)
+}
+
+// DummyPageGetter serves a fixed, in-memory page regardless of the
+// target requested of it. It exists mainly for tests, but is also handy
+// for serving a hand-written root page without a real backing store.
+type DummyPageGetter struct {
+	PageData model.Page
+}
+
+func (d *DummyPageGetter) GetPage(target string) (model.Page, error) {
+	return d.PageData, nil
+}
+
+// SlowPageGetter sleeps for the given duration before returning an empty
+// page. It's used to exercise GetRecords' timeout handling.
+type SlowPageGetter time.Duration
+
+func (s SlowPageGetter) GetPage(target string) (model.Page, error) {
+	time.Sleep(time.Duration(s))
+	return model.Page{}, nil
+}
+
+// StandardPGConfig bundles the default page-getter chain: an
+// AliasPageGetter in front of a SchemePageGetter. Callers populate
+// Alias.Aliases and Scheme.Children, then hand Alias to NewServer.
+type StandardPGConfig struct {
+	Alias  *AliasPageGetter
+	Scheme *SchemePageGetter
+	Logger *standardPGLogger
+}
+
+// standardPGLogger is the writer standard schemes (once registered) log
+// through; it defaults to stderr when NewStandardPGConfig is given nil.
+type standardPGLogger struct {
+	io.Writer
+}
+
+func NewStandardPGConfig(writer io.Writer) StandardPGConfig {
+	if writer == nil {
+		writer = os.Stderr
+	}
+	scheme := &SchemePageGetter{Children: map[string]PageGetter{}}
+	alias := &AliasPageGetter{Aliases: map[string]string{}, Next: scheme}
+	return StandardPGConfig{Alias: alias, Scheme: scheme, Logger: &standardPGLogger{writer}}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/miekg/dns"
+)
+
+// answerFromUpstreams resolves query against s.Upstreams in order,
+// retrying a truncated UDP reply over TCP before moving on to the next
+// upstream. The first usable reply has its Answer merged into response
+// and its Rcode preserved.
+func (s DjdnsServer) answerFromUpstreams(response, query *dns.Msg) (*dns.Msg, error) {
+	upstreamQuery := query.Copy()
+	upstreamQuery.SetEdns0(4096, false)
+
+	client := &dns.Client{Timeout: s.Timeout}
+	tcpClient := &dns.Client{Net: "tcp", Timeout: s.Timeout}
+
+	var lastErr error
+	for _, upstream := range s.Upstreams {
+		reply, _, err := client.Exchange(upstreamQuery, upstream)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if reply.Truncated {
+			reply, _, err = tcpClient.Exchange(upstreamQuery, upstream)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+		}
+
+		response.Rcode = reply.Rcode
+		response.Answer = append(response.Answer, reply.Answer...)
+		return response, nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("No upstream resolvers answered")
+	}
+	response.Rcode = dns.RcodeServerFailure
+	return response, lastErr
+}
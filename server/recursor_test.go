@@ -0,0 +1,149 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/DJDNS/djdns/model"
+	"github.com/miekg/dns"
+)
+
+// startUDPRecursor starts an in-process recursive resolver on
+// 127.0.0.1:0, mirroring the Consul makeRecursor pattern so upstream
+// fallback can be exercised without touching the network.
+func startUDPRecursor(t *testing.T, handler dns.HandlerFunc) (*dns.Server, string) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: handler}
+	go server.ActivateAndServe()
+	return server, pc.LocalAddr().String()
+}
+
+// startTCPRecursorOnPort starts a TCP recursor on the same port as an
+// existing UDP recursor, so a single upstream address answers both.
+func startTCPRecursorOnPort(t *testing.T, port string, handler dns.HandlerFunc) *dns.Server {
+	l, err := net.Listen("tcp", "127.0.0.1:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{Listener: l, Handler: handler}
+	go server.ActivateAndServe()
+	return server
+}
+
+func newUpstreamTestServer() (DjdnsServer, string) {
+	spgc := NewStandardPGConfig(nil)
+	spgc.Alias.Aliases["<ROOT>"] = "root://"
+	spgc.Scheme.Children["root"] = &DummyPageGetter{}
+	return NewServer(spgc.Alias), "upstream.example."
+}
+
+func TestDjdnsServer_UpstreamRecursor(t *testing.T) {
+	answer, _ := dns.NewRR("upstream.example. 300 IN A 9.9.9.9")
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{answer}
+		w.WriteMsg(m)
+	})
+
+	recursor, addr := startUDPRecursor(t, handler)
+	defer recursor.Shutdown()
+
+	s, qname := newUpstreamTestServer()
+	s.Upstreams = []string{addr}
+
+	query := new(dns.Msg)
+	query.SetQuestion(qname, dns.TypeA)
+
+	response, err := s.Handle(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Answer) != 1 {
+		t.Fatalf("Expected 1 answer from upstream, got %d", len(response.Answer))
+	}
+	if response.Rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected RcodeSuccess, got %d", response.Rcode)
+	}
+}
+
+func TestDjdnsServer_UpstreamRecursorTCPFallback(t *testing.T) {
+	full, _ := dns.NewRR("upstream.example. 300 IN A 9.9.9.9")
+
+	udpHandler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		w.WriteMsg(m)
+	})
+	tcpHandler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{full}
+		w.WriteMsg(m)
+	})
+
+	udpRecursor, addr := startUDPRecursor(t, udpHandler)
+	defer udpRecursor.Shutdown()
+
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpRecursor := startTCPRecursorOnPort(t, port, tcpHandler)
+	defer tcpRecursor.Shutdown()
+
+	s, qname := newUpstreamTestServer()
+	s.Upstreams = []string{addr}
+
+	query := new(dns.Msg)
+	query.SetQuestion(qname, dns.TypeA)
+
+	response, err := s.Handle(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Answer) != 1 {
+		t.Fatalf("Expected TCP retry to return the full answer, got %d", len(response.Answer))
+	}
+}
+
+func TestDjdnsServer_RecurseTarget(t *testing.T) {
+	answer, _ := dns.NewRR("delegated.example. 300 IN A 8.8.8.8")
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{answer}
+		w.WriteMsg(m)
+	})
+	recursor, addr := startUDPRecursor(t, handler)
+	defer recursor.Shutdown()
+
+	root := &DummyPageGetter{}
+	root.PageData.Data.Branches = []model.Branch{
+		model.Branch{
+			Selector: "delegated",
+			Targets:  []string{"recurse://"},
+		},
+	}
+
+	spgc := NewStandardPGConfig(nil)
+	spgc.Alias.Aliases["<ROOT>"] = "root://"
+	spgc.Scheme.Children["root"] = root
+	s := NewServer(spgc.Alias)
+	s.Upstreams = []string{addr}
+
+	query := new(dns.Msg)
+	query.SetQuestion("delegated.example.", dns.TypeA)
+
+	response, err := s.Handle(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Answer) != 1 {
+		t.Fatalf("Expected 1 answer via recurse:// delegation, got %d", len(response.Answer))
+	}
+}
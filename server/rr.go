@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"net"
+
+	"github.com/DJDNS/djdns/model"
+	"github.com/miekg/dns"
+)
+
+// ErrUnknownRtype is returned by buildRR when a record's Rtype has no
+// known RR construction.
+var ErrUnknownRtype = errors.New("Unknown Rtype")
+
+// rtypeToDNSType maps a model.Record's Rtype to the miekg/dns type
+// constant used in the resulting RR's header.
+var rtypeToDNSType = map[string]uint16{
+	"A":     dns.TypeA,
+	"AAAA":  dns.TypeAAAA,
+	"CNAME": dns.TypeCNAME,
+	"MX":    dns.TypeMX,
+	"TXT":   dns.TypeTXT,
+	"SRV":   dns.TypeSRV,
+	"NS":    dns.TypeNS,
+}
+
+// RdataFor reports the dns type constant a given Rtype builds, and
+// whether that Rtype is known at all. Callers use it to tell an unknown
+// Rtype apart from a known one whose Rdata failed to parse.
+func RdataFor(rtype string) (rrtype uint16, ok bool) {
+	rrtype, ok = rtypeToDNSType[rtype]
+	return
+}
+
+// buildRR converts a resolved model.Record into a dns.RR. Unknown
+// Rtypes are reported as an error rather than silently dropped.
+func (s DjdnsServer) buildRR(record model.Record) (dns.RR, error) {
+	rrtype, ok := RdataFor(record.Rtype)
+	if !ok {
+		return nil, ErrUnknownRtype
+	}
+	hdr := dns.RR_Header{Name: record.DomainName, Rrtype: rrtype, Class: dns.ClassINET, Ttl: record.TTL}
+
+	switch record.Rtype {
+	case "A":
+		ip, ok := record.Rdata.(string)
+		if !ok {
+			return nil, errors.New("Invalid Rdata for A record")
+		}
+		return &dns.A{Hdr: hdr, A: net.ParseIP(ip).To4()}, nil
+	case "AAAA":
+		ip, ok := record.Rdata.(string)
+		if !ok {
+			return nil, errors.New("Invalid Rdata for AAAA record")
+		}
+		return &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP(ip)}, nil
+	case "CNAME":
+		target, ok := record.Rdata.(string)
+		if !ok {
+			return nil, errors.New("Invalid Rdata for CNAME record")
+		}
+		return &dns.CNAME{Hdr: hdr, Target: target}, nil
+	case "NS":
+		ns, ok := record.Rdata.(string)
+		if !ok {
+			return nil, errors.New("Invalid Rdata for NS record")
+		}
+		return &dns.NS{Hdr: hdr, Ns: ns}, nil
+	case "TXT":
+		txt, ok := record.Rdata.([]string)
+		if !ok {
+			return nil, errors.New("Invalid Rdata for TXT record")
+		}
+		return &dns.TXT{Hdr: hdr, Txt: txt}, nil
+	case "MX":
+		mx, ok := record.Rdata.(model.MXRdata)
+		if !ok {
+			return nil, errors.New("Invalid Rdata for MX record")
+		}
+		return &dns.MX{Hdr: hdr, Preference: mx.Preference, Mx: mx.Mx}, nil
+	case "SRV":
+		srv, ok := record.Rdata.(model.SRVRdata)
+		if !ok {
+			return nil, errors.New("Invalid Rdata for SRV record")
+		}
+		return &dns.SRV{Hdr: hdr, Priority: srv.Priority, Weight: srv.Weight, Port: srv.Port, Target: srv.Target}, nil
+	default:
+		return nil, ErrUnknownRtype
+	}
+}
@@ -0,0 +1,261 @@
+// Package server implements the DJDNS resolver: a dns.Handler that
+// walks a chain of PageGetters to turn a query into a set of records.
+package server
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DJDNS/djdns/model"
+	"github.com/miekg/dns"
+)
+
+// DefaultTimeout bounds how long a single GetRecords call, including any
+// recursion through delegated pages, is allowed to take.
+const DefaultTimeout = 1 * time.Second
+
+// ErrRecurse is returned internally when a branch delegates to
+// "recurse://", signalling that the query should be answered by an
+// upstream recursive resolver instead of the local page chain.
+var ErrRecurse = errors.New("recurse")
+
+// DjdnsServer answers DNS queries by resolving them against a PageGetter
+// chain, falling back to a list of upstream recursive resolvers for
+// anything that chain doesn't claim.
+type DjdnsServer struct {
+	Port       int
+	Logger     *log.Logger
+	PageGetter PageGetter
+	Timeout    time.Duration
+
+	// Upstreams are recursive resolver addresses (host:port) consulted,
+	// in order, whenever no local branch matches a query or a branch
+	// explicitly delegates via "recurse://".
+	Upstreams []string
+
+	// CacheSize bounds how many distinct (qname, qtype, qclass)
+	// resolutions are memoized at once. A non-positive value disables
+	// the cache.
+	CacheSize int
+
+	// NegativeTTL is how long an empty (not-found) answer set is
+	// cached for, since such records carry no TTL of their own.
+	NegativeTTL time.Duration
+
+	udpServer  *dns.Server
+	tcpServer  *dns.Server
+	tlsServer  *dns.Server
+	httpServer *http.Server
+	wg         *sync.WaitGroup
+	cache      *responseCache
+}
+
+// NewServer returns a DjdnsServer ready to resolve queries against pg,
+// with Port set to the standard DJDNS port. Run, RunTLS and RunHTTPS
+// listen on the address passed to them rather than on Port.
+func NewServer(pg PageGetter) DjdnsServer {
+	return DjdnsServer{
+		Port:        9953,
+		Logger:      log.New(os.Stderr, "djdns: ", 0),
+		PageGetter:  pg,
+		Timeout:     DefaultTimeout,
+		CacheSize:   DefaultCacheSize,
+		NegativeTTL: DefaultNegativeTTL,
+		wg:          &sync.WaitGroup{},
+		cache:       newResponseCache(),
+	}
+}
+
+// GetRecords resolves query against the server's page chain, enforcing
+// Timeout across the whole resolution (including any recursion through
+// delegated pages). A nil, nil result means no branch matched; it is not
+// an error.
+func (s DjdnsServer) GetRecords(query string) ([]model.Record, error) {
+	type result struct {
+		records []model.Record
+		err     error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		records, err := s.resolveFrom("<ROOT>", query)
+		ch <- result{records, err}
+	}()
+
+	select {
+	case res := <-ch:
+		return res.records, res.err
+	case <-time.After(s.Timeout):
+		return nil, errors.New("Ran out of time")
+	}
+}
+
+// resolveFrom fetches the page addressed by target and tries to resolve
+// query against it, following Targets recursively.
+func (s DjdnsServer) resolveFrom(target, query string) ([]model.Record, error) {
+	page, err := s.PageGetter.GetPage(target)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := findBranch(page.Data.Branches, query)
+	if branch == nil {
+		return nil, nil
+	}
+
+	if branch.Records != nil {
+		return branch.Records, nil
+	}
+
+	for _, nextTarget := range branch.Targets {
+		if nextTarget == "recurse://" {
+			return nil, ErrRecurse
+		}
+		records, err := s.resolveFrom(nextTarget, query)
+		if err != nil {
+			return nil, err
+		}
+		if records != nil {
+			return records, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// findBranch returns the first branch whose Selector (read as a prefix,
+// with any trailing "*" stripped) matches query, or nil if none do.
+func findBranch(branches []model.Branch, query string) *model.Branch {
+	for i := range branches {
+		prefix := strings.TrimSuffix(branches[i].Selector, "*")
+		if strings.HasPrefix(query, prefix) {
+			return &branches[i]
+		}
+	}
+	return nil
+}
+
+// Handle answers a single DNS query, resolving it locally and, when
+// nothing local matches (or a branch delegates via "recurse://"),
+// falling back to the configured upstream resolvers.
+func (s DjdnsServer) Handle(query *dns.Msg) (*dns.Msg, error) {
+	response := new(dns.Msg)
+	response.Id = query.Id
+	response.Question = query.Question
+	response.Ns = make([]dns.RR, 0)
+	response.Extra = make([]dns.RR, 0)
+
+	if len(query.Question) == 0 {
+		response.Answer = make([]dns.RR, 0)
+		return response, nil
+	}
+
+	question := query.Question[0]
+	key := cacheKey{qname: strings.ToLower(question.Name), qtype: question.Qtype, qclass: question.Qclass}
+	if cached, ok := s.cache.get(key); ok {
+		response.Answer = cached
+		return response, nil
+	}
+
+	records, err := s.GetRecords(question.Name)
+
+	if err == ErrRecurse || (err == nil && records == nil) {
+		if len(s.Upstreams) > 0 {
+			return s.answerFromUpstreams(response, query)
+		}
+		if err == ErrRecurse {
+			response.Rcode = dns.RcodeServerFailure
+			return response, err
+		}
+	}
+
+	if err != nil {
+		response.Rcode = dns.RcodeServerFailure
+		return response, err
+	}
+
+	answers := make([]dns.RR, 0, len(records))
+	for _, record := range records {
+		rr, err := s.buildRR(record)
+		if err != nil {
+			response.Rcode = dns.RcodeServerFailure
+			return response, err
+		}
+		if rr.Header().Rrtype != question.Qtype {
+			continue
+		}
+		answers = append(answers, rr)
+	}
+	response.Answer = answers
+	s.cache.put(key, answers, s.NegativeTTL, s.CacheSize)
+
+	return response, nil
+}
+
+// Run starts a UDP and a TCP listener in parallel, sharing the same
+// handler, and blocks until either stops (typically via Close). A TCP
+// listener is required alongside UDP so that clients which receive a
+// truncated UDP reply have somewhere to retry.
+func (s *DjdnsServer) Run(addr string) error {
+	handler := s.handler()
+	s.udpServer = &dns.Server{Addr: addr, Net: "udp", Handler: handler}
+	s.tcpServer = &dns.Server{Addr: addr, Net: "tcp", Handler: handler}
+
+	errs := make(chan error, 2)
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		errs <- s.udpServer.ListenAndServe()
+	}()
+	go func() {
+		defer s.wg.Done()
+		errs <- s.tcpServer.ListenAndServe()
+	}()
+
+	return <-errs
+}
+
+// Close shuts down any listeners started by Run and waits for Run to
+// return.
+func (s *DjdnsServer) Close() error {
+	if s.udpServer != nil {
+		if err := s.udpServer.Shutdown(); err != nil {
+			return err
+		}
+	}
+	if s.tcpServer != nil {
+		if err := s.tcpServer.Shutdown(); err != nil {
+			return err
+		}
+	}
+	if s.tlsServer != nil {
+		if err := s.tlsServer.Shutdown(); err != nil {
+			return err
+		}
+	}
+	if s.httpServer != nil {
+		if err := s.httpServer.Close(); err != nil {
+			return err
+		}
+	}
+	s.wg.Wait()
+	return nil
+}
+
+func (s *DjdnsServer) handler() dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, query *dns.Msg) {
+		response, err := s.Handle(query)
+		if err == ErrUnknownRtype {
+			s.Logger.Println(err)
+		}
+		_, tcp := w.RemoteAddr().(*net.TCPAddr)
+		fitResponse(query, response, tcp)
+		w.WriteMsg(response)
+	})
+}
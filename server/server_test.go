@@ -1,7 +1,6 @@
 package server
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"log"
@@ -78,6 +77,56 @@ func setupTestData(writer io.Writer) (DjdnsServer, StandardPGConfig) {
 			Selector: "slow*",
 			Targets:  []string{"slow://"},
 		},
+		model.Branch{
+			Selector: "ttl",
+			TTL:      60,
+			Records: []model.Record{
+				model.Record{
+					DomainName: "short.lived",
+					Rdata:      "4.4.4.4",
+				},
+			},
+		},
+		model.Branch{
+			Selector: "types",
+			Records: []model.Record{
+				model.Record{
+					DomainName: "types.example",
+					Rtype:      "A",
+					Rdata:      "5.5.5.5",
+				},
+				model.Record{
+					DomainName: "types.example",
+					Rtype:      "AAAA",
+					Rdata:      "::5",
+				},
+				model.Record{
+					DomainName: "types.example",
+					Rtype:      "CNAME",
+					Rdata:      "canonical.example.",
+				},
+				model.Record{
+					DomainName: "types.example",
+					Rtype:      "MX",
+					Rdata:      model.MXRdata{Preference: 10, Mx: "mail.example."},
+				},
+				model.Record{
+					DomainName: "types.example",
+					Rtype:      "TXT",
+					Rdata:      []string{"hello world"},
+				},
+				model.Record{
+					DomainName: "types.example",
+					Rtype:      "SRV",
+					Rdata:      model.SRVRdata{Priority: 1, Weight: 2, Port: 5060, Target: "sip.example."},
+				},
+				model.Record{
+					DomainName: "types.example",
+					Rtype:      "NS",
+					Rdata:      "ns1.example.",
+				},
+			},
+		},
 	}
 	root.PageData.Data.Normalize()
 
@@ -208,16 +257,37 @@ func testResolution(t *testing.T, tester ResolveTester, rt ResolveTest) {
 
 	// DNS package tends to be loose about some encoding details,
 	// only calculating them right before putting the data on the
-	// wire.
+	// wire. It's also inconsistent about A addresses: the zone-file
+	// parser keeps net.ParseIP's 16-byte form, but a real wire
+	// round-trip always comes back as the canonical 4-byte form.
 	sanitize := func(rr_list []dns.RR) {
 		for i := range rr_list {
 			rr_list[i].Header().Rdlength = 0
+			if a, ok := rr_list[i].(*dns.A); ok {
+				a.A = a.A.To4()
+			}
+		}
+	}
+	// A tester that genuinely round-trips over the wire always comes
+	// back with nil Ns/Extra/Answer for a zero-count section, while
+	// expected builds them as empty (non-nil) slices; treat the two
+	// as equivalent.
+	denil := func(msg *dns.Msg) {
+		if msg.Answer == nil {
+			msg.Answer = make([]dns.RR, 0)
+		}
+		if msg.Ns == nil {
+			msg.Ns = make([]dns.RR, 0)
+		}
+		if msg.Extra == nil {
+			msg.Extra = make([]dns.RR, 0)
 		}
 	}
 	for _, msg := range []*dns.Msg{response, expected} {
 		sanitize(msg.Answer)
 		sanitize(msg.Ns)
 		sanitize(msg.Extra)
+		denil(msg)
 	}
 
 	// Confirm equality
@@ -317,6 +387,86 @@ var resolve_tests = []ResolveTest{
 			"only.smells. A 3.3.3.3",
 		},
 	},
+	ResolveTest{
+		Description: "Branch TTL override",
+		QuestionSection: []dns.Question{
+			dns.Question{
+				"ttl.example.", dns.TypeA, dns.ClassINET},
+		},
+		ExpectedAnswers: []string{
+			"short.lived. 60 IN A 4.4.4.4",
+		},
+	},
+	ResolveTest{
+		Description: "A-typed query filters out other types in the branch",
+		QuestionSection: []dns.Question{
+			dns.Question{
+				"types.example.", dns.TypeA, dns.ClassINET},
+		},
+		ExpectedAnswers: []string{
+			"types.example. A 5.5.5.5",
+		},
+	},
+	ResolveTest{
+		Description: "AAAA record",
+		QuestionSection: []dns.Question{
+			dns.Question{
+				"types.example.", dns.TypeAAAA, dns.ClassINET},
+		},
+		ExpectedAnswers: []string{
+			"types.example. AAAA ::5",
+		},
+	},
+	ResolveTest{
+		Description: "CNAME record",
+		QuestionSection: []dns.Question{
+			dns.Question{
+				"types.example.", dns.TypeCNAME, dns.ClassINET},
+		},
+		ExpectedAnswers: []string{
+			"types.example. CNAME canonical.example.",
+		},
+	},
+	ResolveTest{
+		Description: "MX record",
+		QuestionSection: []dns.Question{
+			dns.Question{
+				"types.example.", dns.TypeMX, dns.ClassINET},
+		},
+		ExpectedAnswers: []string{
+			"types.example. MX 10 mail.example.",
+		},
+	},
+	ResolveTest{
+		Description: "TXT record",
+		QuestionSection: []dns.Question{
+			dns.Question{
+				"types.example.", dns.TypeTXT, dns.ClassINET},
+		},
+		ExpectedAnswers: []string{
+			"types.example. TXT \"hello world\"",
+		},
+	},
+	ResolveTest{
+		Description: "SRV record",
+		QuestionSection: []dns.Question{
+			dns.Question{
+				"types.example.", dns.TypeSRV, dns.ClassINET},
+		},
+		ExpectedAnswers: []string{
+			"types.example. SRV 1 2 5060 sip.example.",
+		},
+	},
+	ResolveTest{
+		Description: "NS record",
+		QuestionSection: []dns.Question{
+			dns.Question{
+				"types.example.", dns.TypeNS, dns.ClassINET},
+		},
+		ExpectedAnswers: []string{
+			"types.example. NS ns1.example.",
+		},
+	},
 	ResolveTest{
 		Description: "Timeout",
 		QuestionSection: []dns.Question{
@@ -335,16 +485,66 @@ func Test_DjdnsServer_Handle(t *testing.T) {
 	}
 }
 
+// countingPageGetter wraps another PageGetter and counts how many times
+// GetPage is called through it.
+type countingPageGetter struct {
+	Inner PageGetter
+	Calls int
+}
+
+func (c *countingPageGetter) GetPage(target string) (model.Page, error) {
+	c.Calls++
+	return c.Inner.GetPage(target)
+}
+
+func Test_DjdnsServer_Handle_Cache(t *testing.T) {
+	root := &DummyPageGetter{}
+	root.PageData.Data.Branches = []model.Branch{
+		model.Branch{
+			Selector: "cached",
+			Records: []model.Record{
+				model.Record{
+					DomainName: "cached.example",
+					Rdata:      "9.9.9.9",
+				},
+			},
+		},
+	}
+	root.PageData.Data.Normalize()
+	counting := &countingPageGetter{Inner: root}
+
+	spgc := NewStandardPGConfig(nil)
+	spgc.Alias.Aliases["<ROOT>"] = "root://"
+	spgc.Scheme.Children["root"] = counting
+	s := NewServer(spgc.Alias)
+
+	query := new(dns.Msg)
+	query.Question = []dns.Question{
+		dns.Question{"cached.example.", dns.TypeA, dns.ClassINET},
+	}
+
+	if _, err := s.Handle(query); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Handle(query); err != nil {
+		t.Fatal(err)
+	}
+
+	if counting.Calls != 1 {
+		t.Fatalf("Expected page getter to be called once, got %d calls", counting.Calls)
+	}
+}
+
 func Test_DjdnsServer_Run(t *testing.T) {
-	buf := new(bytes.Buffer)
+	buf := new(syncBuffer)
 	s, _ := setupTestData(buf)
 	host, port := "127.0.0.1", 9953
 	addr := fmt.Sprintf("%s:%d", host, port)
 
+	runErr := make(chan error, 1)
 	go func() {
-		t.Fatal(s.Run(addr))
+		runErr <- s.Run(addr)
 	}()
-	defer s.Close()
 	<-time.After(50 * time.Millisecond)
 
 	c := new(dns.Client)
@@ -355,4 +555,11 @@ func Test_DjdnsServer_Run(t *testing.T) {
 
 	expected_log := "djdns: Unknown Rtype\n"
 	assert.Equal(t, expected_log, buf.String())
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatal(err)
+	}
 }
@@ -0,0 +1,43 @@
+package server
+
+import "github.com/miekg/dns"
+
+// udpMinSize is the UDP payload size every resolver must be able to
+// receive when the query carried no EDNS0 OPT record (RFC 1035).
+const udpMinSize = 512
+
+// maxResponseSize returns how large response may be for the given
+// query and transport: the client's advertised EDNS0 buffer size over
+// UDP, 512 bytes over UDP without EDNS0, or unbounded over TCP.
+func maxResponseSize(query *dns.Msg, tcp bool) int {
+	if tcp {
+		return dns.MaxMsgSize
+	}
+	if opt := query.IsEdns0(); opt != nil {
+		if size := int(opt.UDPSize()); size > udpMinSize {
+			return size
+		}
+	}
+	return udpMinSize
+}
+
+// fitResponse trims response's Answer section, setting Truncated, until
+// it packs within the size query's transport and EDNS0 negotiation
+// allow. TCP responses are never trimmed.
+func fitResponse(query, response *dns.Msg, tcp bool) {
+	limit := maxResponseSize(query, tcp)
+
+	packed, err := response.Pack()
+	if err != nil || len(packed) <= limit {
+		return
+	}
+
+	response.Truncated = true
+	for len(response.Answer) > 0 {
+		response.Answer = response.Answer[:len(response.Answer)-1]
+		packed, err = response.Pack()
+		if err == nil && len(packed) <= limit {
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DJDNS/djdns/model"
+	"github.com/miekg/dns"
+)
+
+func bigAnswerServer() DjdnsServer {
+	root := &DummyPageGetter{}
+	records := make([]model.Record, 0, 64)
+	for i := 0; i < 64; i++ {
+		records = append(records, model.Record{
+			DomainName: fmt.Sprintf("host%d.big.", i),
+			Rdata:      fmt.Sprintf("10.0.%d.%d", i/256, i%256),
+		})
+	}
+	root.PageData.Data.Branches = []model.Branch{
+		model.Branch{Selector: "big", Records: records},
+	}
+	root.PageData.Data.Normalize()
+
+	spgc := NewStandardPGConfig(nil)
+	spgc.Alias.Aliases["<ROOT>"] = "root://"
+	spgc.Scheme.Children["root"] = root
+	return NewServer(spgc.Alias)
+}
+
+func TestFitResponse_UDPTruncates(t *testing.T) {
+	s := bigAnswerServer()
+
+	query := new(dns.Msg)
+	query.SetQuestion("big.example.", dns.TypeA)
+
+	response, err := s.Handle(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Answer) != 64 {
+		t.Fatalf("Expected 64 answers before truncation, got %d", len(response.Answer))
+	}
+
+	fitResponse(query, response, false)
+
+	if !response.Truncated {
+		t.Fatal("Expected Truncated to be set")
+	}
+	packed, err := response.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packed) > udpMinSize {
+		t.Fatalf("Expected packed response to fit in %d bytes, got %d", udpMinSize, len(packed))
+	}
+	if len(response.Answer) == 0 {
+		t.Fatal("Expected at least some answers to survive truncation")
+	}
+}
+
+func TestFitResponse_EDNS0RaisesLimit(t *testing.T) {
+	s := bigAnswerServer()
+
+	query := new(dns.Msg)
+	query.SetQuestion("big.example.", dns.TypeA)
+	query.SetEdns0(4096, false)
+
+	response, err := s.Handle(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fitResponse(query, response, false)
+
+	if response.Truncated {
+		t.Fatal("Expected a large EDNS0 buffer size not to require truncation")
+	}
+	if len(response.Answer) != 64 {
+		t.Fatalf("Expected all 64 answers to survive, got %d", len(response.Answer))
+	}
+}
+
+func TestFitResponse_TCPNeverTruncates(t *testing.T) {
+	s := bigAnswerServer()
+
+	query := new(dns.Msg)
+	query.SetQuestion("big.example.", dns.TypeA)
+
+	response, err := s.Handle(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fitResponse(query, response, true)
+
+	if response.Truncated {
+		t.Fatal("Did not expect Truncated to be set for a TCP response")
+	}
+	if len(response.Answer) != 64 {
+		t.Fatalf("Expected all 64 answers over TCP, got %d", len(response.Answer))
+	}
+}